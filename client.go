@@ -1,12 +1,14 @@
 package workers
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -16,27 +18,93 @@ import (
 // ErrClientHasQuit is returned by Client when it is quitting
 var ErrClientHasQuit = errors.New("client has quit")
 
+// defaultReconnectMinWait and defaultReconnectMaxWait bound the backoff
+// used by ConnectAndWork when ReconnectMinWait/ReconnectMaxWait are unset.
+const (
+	defaultReconnectMinWait = 100 * time.Millisecond
+	defaultReconnectMaxWait = 30 * time.Second
+)
+
 // Client defines parameters for running an beanstalk client.
 type Client struct {
 	Network    string
 	Addr       string
 	Handler    Handler
-	mu         sync.Mutex // guards stop
-	stop       chan error
-	wait       int64
 	MaxControl chan int
+	wait       int64
+
+	// Metrics, if set, is notified of job and connection lifecycle events.
+	// See the prometheus subpackage for a ready-made implementation.
+	Metrics Metrics
+
+	activeWorkers int32
+
+	// ReconnectMinWait and ReconnectMaxWait bound the exponential backoff
+	// ConnectAndWork applies between redial attempts after a transient
+	// connection error. They default to 100ms and 30s respectively.
+	ReconnectMinWait time.Duration
+	ReconnectMaxWait time.Duration
+
+	mu       sync.Mutex // guards stopped
+	quit     chan struct{}
+	quitOnce sync.Once
+	stopped  bool
+
+	inflight sync.Map // *Job -> struct{}, jobs currently in a handler
 }
 
-// ConnectAndWork connects on the c.Network and c.Addr and then
-// calls Reserve to handle jobs on the beanstalk instance.
+// ConnectAndWork connects on the c.Network and c.Addr and then calls
+// Reserve to handle jobs on the beanstalk instance. If the connection is
+// lost to a transient error (a dropped TCP connection, a beanstalkd
+// restart, and the like) it redials with exponential backoff and resumes
+// reserving, rather than giving up the first time the socket breaks.
 func (c *Client) ConnectAndWork() error {
-	conn, err := net.Dial(c.Network, c.Addr)
+	return c.ConnectAndWorkContext(context.Background())
+}
+
+// ConnectAndWorkContext is ConnectAndWork with a Context propagated into
+// every reserved Job, so handlers can observe shutdown. Canceling ctx
+// stops reserving and reconnecting just like Stop.
+func (c *Client) ConnectAndWorkContext(ctx context.Context) error {
+	c.initQuit()
 
-	if err != nil {
-		return err
+	wait := c.ReconnectMinWait
+	if wait <= 0 {
+		wait = defaultReconnectMinWait
+	}
+	max := c.ReconnectMaxWait
+	if max <= 0 {
+		max = defaultReconnectMaxWait
 	}
 
-	return c.Reserve(conn)
+	for {
+		conn, err := net.Dial(c.Network, c.Addr)
+		if err == nil {
+			err = c.ReserveContext(ctx, conn)
+		}
+
+		if err == ErrClientHasQuit {
+			return err
+		}
+		if !isReconnectableError(err) {
+			return err
+		}
+
+		select {
+		case <-c.quit:
+			return ErrClientHasQuit
+		case <-ctx.Done():
+			return ErrClientHasQuit
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+		if wait > max {
+			wait = max
+		}
+
+		c.metrics().OnReconnect()
+	}
 }
 
 // ConnectAndWork creates a client, connects to the beanstalk instance and
@@ -47,57 +115,161 @@ func ConnectAndWork(network string, addr string, wait int64, max int64, handler
 	return client.ConnectAndWork()
 }
 
+// ConnectAndWorkContext is ConnectAndWork with a Context propagated into
+// every reserved Job.
+func ConnectAndWorkContext(ctx context.Context, network string, addr string, wait int64, max int64, handler Handler) error {
+	client := &Client{Network: network, Addr: addr, wait: wait, Handler: handler}
+	client.MaxControl = make(chan int, max)
+	return client.ConnectAndWorkContext(ctx)
+}
+
 // Reserve accepts incoming jobs on the beanstalk.Conn conn, creating a
 // new service goroutine for each. The service goroutines read the job and
 // then call c.Handler to process them.
 func (c *Client) Reserve(conn io.ReadWriteCloser) error {
-	c.mu.Lock()
-	c.stop = make(chan error)
-	c.mu.Unlock()
+	return c.ReserveContext(context.Background(), conn)
+}
+
+// ReserveContext is Reserve with a Context propagated into every reserved
+// Job. Canceling ctx stops reserving new jobs, same as Stop.
+func (c *Client) ReserveContext(ctx context.Context, conn io.ReadWriteCloser) error {
+	c.initQuit()
 	bs := beanstalk.NewConn(conn)
 	tubes := c.tubes(bs)
+
+	mux, _ := c.Handler.(*WorkMux)
+
+	// jobCtx is what's handed to reserved Jobs: it's canceled when ctx is
+	// canceled (automatically, since it's derived from ctx) and also when
+	// c.quit is closed by Stop/StopWithTimeout/a signal, so Job.Context()
+	// actually observes shutdown regardless of which entry point is used.
+	jobCtx, cancelJobCtx := context.WithCancel(ctx)
+	defer cancelJobCtx()
+
+	done := make(chan struct{})
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
-	go c.quitOnSignal(wg)
+	go c.quitOnSignal(wg, done)
+	wg.Add(1)
+	go c.cancelOnQuit(wg, done, cancelJobCtx)
 
+	// Defers run LIFO: close(done) must unblock quitOnSignal before
+	// wg.Wait() blocks on it, so it's deferred after wg.Wait().
 	defer bs.Close()
 	defer wg.Wait()
+	defer close(done)
 
 	for {
 		wait := time.Duration(c.wait) * time.Millisecond // how long to sleep when no jobs in queues
 
-		for name, tube := range tubes {
+		// Recomputed every pass so a tube registered with mux after this
+		// Reserve call started (e.g. PubSub.Subscribe on a new topic) is
+		// picked up, instead of being stuck with the tubes snapshotted at
+		// the top of this call.
+		c.syncTubes(mux, bs, tubes)
+		order := c.tubeOrder(mux, tubes)
+
+		for _, name := range order {
+			tube := tubes[name]
+
+			release, ok := acquireTubeSlot(mux, name)
+			if !ok {
+				continue // tube is at its per-tube concurrency limit
+			}
+
 			select {
 			case c.MaxControl <- 0:
 				id, body, err := tube.Reserve(0 /* don't block others */)
 				if err == nil {
+					c.metrics().OnJobReserved(name)
 					wg.Add(1)
-					go c.work(wg, NewJob(bs, name, id, body))
+					go c.work(wg, NewJob(jobCtx, bs, name, id, body), release)
 				} else if !isTimeoutOrDeadline(err) {
-					c.Stop()
+					<-c.MaxControl
+					release()
+					c.metrics().OnReserveError(err)
 					return err
 				} else {
 					<-c.MaxControl
+					release()
 				}
-			case <-c.stop:
+			case <-c.quit:
+				release()
+				return ErrClientHasQuit
+			case <-ctx.Done():
+				release()
 				return ErrClientHasQuit
 			default:
+				release()
 			}
 		}
 
 		select {
-		case <-c.stop:
+		case <-c.quit:
+			return ErrClientHasQuit
+		case <-ctx.Done():
 			return ErrClientHasQuit
 		case <-time.After(wait):
 		}
 	}
 }
 
-// Stop stops reserving jobs and wait for current workers to finish their job.
+// Stop stops reserving jobs and returns at once; Reserve then waits for
+// current workers to finish their job before it returns. It is safe to
+// call Stop more than once or concurrently.
 func (c *Client) Stop() {
+	c.initQuit()
+
 	c.mu.Lock()
-	close(c.stop)
-	c.mu.Unlock()
+	defer c.mu.Unlock()
+
+	if !c.stopped {
+		c.stopped = true
+		close(c.quit)
+	}
+}
+
+// StopWithTimeout stops reserving new jobs, the same as Stop, and waits up
+// to d for in-flight jobs to finish. If the deadline passes while jobs are
+// still being processed, their reservations are released back to
+// beanstalk so they become available to other workers immediately rather
+// than waiting for their TTR to expire.
+func (c *Client) StopWithTimeout(d time.Duration) {
+	c.Stop()
+
+	deadline := time.After(d)
+	for c.anyInflight() {
+		select {
+		case <-deadline:
+			c.releaseInflight()
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (c *Client) anyInflight() bool {
+	any := false
+	c.inflight.Range(func(_, _ interface{}) bool {
+		any = true
+		return false
+	})
+	return any
+}
+
+func (c *Client) releaseInflight() {
+	c.inflight.Range(func(key, _ interface{}) bool {
+		if j, ok := key.(*Job); ok {
+			j.Release(0, 0)
+		}
+		return true
+	})
+}
+
+func (c *Client) initQuit() {
+	c.quitOnce.Do(func() {
+		c.quit = make(chan struct{})
+	})
 }
 
 func (c *Client) tubes(conn *beanstalk.Conn) map[string]*beanstalk.TubeSet {
@@ -115,25 +287,119 @@ func (c *Client) tubes(conn *beanstalk.Conn) map[string]*beanstalk.TubeSet {
 	return tubes
 }
 
-func (c *Client) work(wg *sync.WaitGroup, j *Job) {
+// syncTubes adds a *beanstalk.TubeSet for any tube mux has registered
+// since tubes was built, so tubes subscribed to at runtime (e.g. via
+// PubSub.Subscribe) are picked up without requiring a reconnect. It never
+// removes a tube, even if mux no longer has a handler for it.
+func (c *Client) syncTubes(mux *WorkMux, conn *beanstalk.Conn, tubes map[string]*beanstalk.TubeSet) {
+	if mux == nil {
+		return
+	}
+
+	for _, name := range mux.Tubes() {
+		if _, ok := tubes[name]; !ok {
+			tubes[name] = beanstalk.NewTubeSet(conn, name)
+		}
+	}
+}
+
+// tubeOrder returns the order in which Reserve should poll tubes. When
+// mux is non-nil its tubes are interleaved by weight; otherwise tubes are
+// polled in map order.
+func (c *Client) tubeOrder(mux *WorkMux, tubes map[string]*beanstalk.TubeSet) []string {
+	if mux != nil {
+		if order := mux.weightedTubes(); len(order) > 0 {
+			return order
+		}
+	}
+
+	order := make([]string, 0, len(tubes))
+	for name := range tubes {
+		order = append(order, name)
+	}
+
+	return order
+}
+
+// acquireTubeSlot reserves a per-tube concurrency slot for name via mux,
+// if mux is non-nil and name has a MaxConcurrent limit configured.
+func acquireTubeSlot(mux *WorkMux, name string) (release func(), ok bool) {
+	if mux == nil {
+		return func() {}, true
+	}
+
+	return mux.acquire(name)
+}
+
+func (c *Client) work(wg *sync.WaitGroup, j *Job, releaseTube func()) {
 	defer wg.Done()
+	defer func() { <-c.MaxControl }()
+	defer releaseTube()
+
+	c.inflight.Store(j, struct{}{})
+	defer c.inflight.Delete(j)
+
+	atomic.AddInt32(&c.activeWorkers, 1)
+	defer atomic.AddInt32(&c.activeWorkers, -1)
+
+	outcome := "ok"
+	start := time.Now()
+
+	// recoverHandler must run before this defer so outcome reflects a
+	// panic by the time OnJobCompleted reads it (defers run LIFO).
+	defer func() { c.metrics().OnJobCompleted(j.Tube, time.Since(start), outcome) }()
+	defer c.recoverHandler(j, &outcome)
+
 	c.Handler.Work(j)
-	<-c.MaxControl
 }
 
-func (c *Client) quitOnSignal(wg *sync.WaitGroup) {
+// recoverHandler stops a panicking Handler from taking down the worker
+// pool: the job is released back to beanstalk so another reserve can pick
+// it up, rather than sitting reserved until its TTR expires. A job that an
+// ErrorPolicy has already disposed of is no longer reserved, so that
+// release fails and its error is ignored.
+func (c *Client) recoverHandler(j *Job, outcome *string) {
+	if r := recover(); r != nil {
+		*outcome = "panic"
+		j.Release(0, 0)
+	}
+}
+
+// ActiveWorkers reports how many jobs are currently being processed by
+// handlers.
+func (c *Client) ActiveWorkers() int {
+	return int(atomic.LoadInt32(&c.activeWorkers))
+}
+
+func (c *Client) quitOnSignal(wg *sync.WaitGroup, done <-chan struct{}) {
 	defer wg.Done()
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigchan)
 
 	select {
-	case <-c.stop:
+	case <-done:
+	case <-c.quit:
 	case <-sigchan:
 		c.Stop()
 	}
 }
 
+// cancelOnQuit calls cancel as soon as c.quit is closed, so jobCtx
+// reflects an explicit Stop/signal rather than only ctx's own
+// cancellation. It exits without canceling once done closes, i.e. once
+// this Reserve call is already returning on its own (e.g. to reconnect).
+func (c *Client) cancelOnQuit(wg *sync.WaitGroup, done <-chan struct{}, cancel context.CancelFunc) {
+	defer wg.Done()
+
+	select {
+	case <-c.quit:
+		cancel()
+	case <-done:
+	}
+}
+
 func isTimeoutOrDeadline(err error) bool {
 	if connerr, isConnErr := err.(beanstalk.ConnError); isConnErr {
 		return connerr.Op == "reserve-with-timeout" &&
@@ -142,3 +408,26 @@ func isTimeoutOrDeadline(err error) bool {
 
 	return false
 }
+
+// isReconnectableError reports whether err looks like a transient network
+// failure (a dropped connection, an I/O error, a beanstalkd restart)
+// rather than a fatal protocol error, and so is worth redialing for.
+func isReconnectableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	if connErr, isConnErr := err.(beanstalk.ConnError); isConnErr {
+		return isReconnectableError(connErr.Err)
+	}
+
+	if _, isNetErr := err.(net.Error); isNetErr {
+		return true
+	}
+
+	return false
+}
@@ -1,6 +1,7 @@
 package workers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -101,6 +102,42 @@ func TestClientStopsOnSIGINT(t *testing.T) {
 	}
 }
 
+func TestIsReconnectableError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, true},
+		{io.ErrUnexpectedEOF, true},
+		{beanstalk.ConnError{Op: "read", Err: io.EOF}, true},
+		{errors.New("boom"), false},
+	}
+
+	for _, c := range cases {
+		if got := isReconnectableError(c.err); got != c.want {
+			t.Errorf("isReconnectableError(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestStopIsIdempotent(t *testing.T) {
+	client := &Client{MaxControl: make(chan int, 1)}
+
+	client.Stop()
+	client.Stop() // must not panic
+}
+
+func TestStopWithTimeoutReturnsWhenIdle(t *testing.T) {
+	client := &Client{MaxControl: make(chan int, 1)}
+
+	start := time.Now()
+	client.StopWithTimeout(time.Second)
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatal("StopWithTimeout should return immediately with no in-flight jobs")
+	}
+}
+
 func TestReserveIsParallelAndWaits(t *testing.T) {
 	count := int32(0)
 	tubeName := strconv.Itoa(int(time.Now().Unix()))
@@ -0,0 +1,116 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HandlerEFunc is an adapter like HandlerFunc for handlers that can fail.
+// Use WorkMux.HandleE to register one along with the ErrorPolicy that
+// governs what happens to a job when fn returns an error.
+type HandlerEFunc func(j *Job) error
+
+// PoisonJob wraps an error returned by a HandlerEFunc to mark the job as
+// unrecoverable, e.g. a payload that can never be parsed. ErrorPolicy
+// deletes jobs whose error unwraps to a *PoisonJob instead of releasing
+// or burying them.
+type PoisonJob struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (p *PoisonJob) Error() string {
+	return p.Err.Error()
+}
+
+// Unwrap allows errors.As/errors.Is to see the wrapped error.
+func (p *PoisonJob) Unwrap() error {
+	return p.Err
+}
+
+// ErrorPolicy controls how a job is disposed of after its handler returns
+// an error or panics: it is released with a backoff delay up to
+// MaxRetries reserves, then buried so it doesn't spin forever. A handler
+// can mark a job as unrecoverable by returning a *PoisonJob, which is
+// deleted immediately regardless of MaxRetries.
+type ErrorPolicy struct {
+	// MaxRetries is how many times a job may be reserved (per beanstalk's
+	// "reserves" job stat) before it is buried instead of released. Zero
+	// means retry indefinitely.
+	MaxRetries int
+
+	// Backoff computes the release delay for the given reserve attempt
+	// (1-indexed). DefaultBackoff is used when nil.
+	Backoff func(attempt int) time.Duration
+}
+
+// DefaultBackoff doubles the delay for each attempt, starting at one
+// second and capping at one minute.
+func DefaultBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := time.Second << uint(attempt-1)
+	if d <= 0 || d > time.Minute {
+		return time.Minute
+	}
+
+	return d
+}
+
+// apply disposes of j following the policy after its handler returned
+// cause: a *PoisonJob is deleted outright, a job that has exhausted
+// MaxRetries is buried, and anything else is released with a backoff
+// delay.
+func (p ErrorPolicy) apply(j *Job, cause error) {
+	var poison *PoisonJob
+	if errors.As(cause, &poison) {
+		j.Delete()
+		return
+	}
+
+	attempt := j.reserves()
+
+	if p.MaxRetries > 0 && attempt >= p.MaxRetries {
+		j.Bury(0)
+		return
+	}
+
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = DefaultBackoff
+	}
+
+	j.Release(0, backoff(attempt))
+}
+
+// errHandler adapts a HandlerEFunc into a Handler, applying policy to any
+// job whose handler returns an error or panics. A panic is re-raised after
+// the policy has run, so the Client still records the job's outcome as a
+// panic.
+type errHandler struct {
+	fn     HandlerEFunc
+	policy ErrorPolicy
+}
+
+func (h errHandler) Work(j *Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.policy.apply(j, fmt.Errorf("workers: handler panicked: %v", r))
+			panic(r)
+		}
+	}()
+
+	if err := h.fn(j); err != nil {
+		h.policy.apply(j, err)
+	}
+}
+
+// HandleE registers fn as the handler for tube. If fn returns an error,
+// the job is released, buried, or (for a *PoisonJob) deleted according to
+// policy instead of being left for the caller to dispose of.
+func (mux *WorkMux) HandleE(tube string, fn HandlerEFunc, policy ErrorPolicy) {
+	mux.Handle(tube, errHandler{fn: fn, policy: policy})
+}
@@ -0,0 +1,97 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kr/beanstalk"
+)
+
+func TestDefaultBackoff(t *testing.T) {
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{10, time.Minute},
+	}
+
+	for _, c := range cases {
+		if got := DefaultBackoff(c.attempt); got != c.want {
+			t.Errorf("DefaultBackoff(%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestErrorPolicyApply(t *testing.T) {
+	tubeName := strconv.Itoa(int(time.Now().UnixNano()))
+
+	producer, _ := beanstalk.Dial("tcp", "localhost:11300")
+	tube := &beanstalk.Tube{Conn: producer, Name: tubeName}
+
+	consumer, _ := beanstalk.Dial("tcp", "localhost:11300")
+	tubeSet := beanstalk.NewTubeSet(consumer, tubeName)
+
+	newJob := func(t *testing.T, body string) *Job {
+		t.Helper()
+
+		id, err := tube.Put([]byte(body), 0, 0, time.Minute)
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+
+		rid, rbody, err := tubeSet.Reserve(time.Second)
+		if err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+		if rid != id {
+			t.Fatalf("reserved job %d, expected %d", rid, id)
+		}
+
+		return NewJob(context.Background(), consumer, tubeName, rid, rbody)
+	}
+
+	t.Run("poison job is deleted", func(t *testing.T) {
+		j := newJob(t, "poison")
+
+		ErrorPolicy{}.apply(j, &PoisonJob{Err: errors.New("bad payload")})
+
+		if _, err := j.Stats(); err == nil {
+			t.Fatal("expected poison job to no longer exist")
+		}
+	})
+
+	t.Run("job is released below MaxRetries", func(t *testing.T) {
+		j := newJob(t, "retry")
+
+		ErrorPolicy{MaxRetries: 5}.apply(j, errors.New("boom"))
+
+		stats, err := j.Stats()
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		if stats["state"] != "ready" && stats["state"] != "delayed" {
+			t.Fatalf("expected job to be released, got state %q", stats["state"])
+		}
+	})
+
+	t.Run("job is buried once MaxRetries is reached", func(t *testing.T) {
+		j := newJob(t, "poison-by-retries")
+
+		ErrorPolicy{MaxRetries: 1}.apply(j, errors.New("boom"))
+
+		stats, err := j.Stats()
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		if stats["state"] != "buried" {
+			t.Fatalf("expected job to be buried, got state %q", stats["state"])
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package workers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kr/beanstalk"
+)
+
+// Job represents a single reserved beanstalk job, delivered to a Handler
+// for processing.
+type Job struct {
+	conn *beanstalk.Conn
+	ctx  context.Context
+	Tube string
+	ID   uint64
+	Body []byte
+}
+
+// NewJob creates a Job wrapping a reserved id/body pair from tube, whose
+// Context is canceled when the Client that reserved it is shutting down.
+func NewJob(ctx context.Context, conn *beanstalk.Conn, tube string, id uint64, body []byte) *Job {
+	return &Job{conn: conn, ctx: ctx, Tube: tube, ID: id, Body: body}
+}
+
+// Context is canceled when the Client reserving this job is shutting
+// down, so long-running handlers can observe it and voluntarily Release
+// rather than run to completion.
+func (j *Job) Context() context.Context {
+	return j.ctx
+}
+
+// Delete removes the job from the queue.
+func (j *Job) Delete() error {
+	return j.conn.Delete(j.ID)
+}
+
+// Release puts the job back into the ready queue with the given priority,
+// to be reserved again after delay.
+func (j *Job) Release(pri uint32, delay time.Duration) error {
+	return j.conn.Release(j.ID, pri, delay)
+}
+
+// Bury marks the job as buried, removing it from the ready queue until it
+// is kicked.
+func (j *Job) Bury(pri uint32) error {
+	return j.conn.Bury(j.ID, pri)
+}
+
+// Touch resets the job's reservation timer, giving the handler more time
+// before the job is considered timed out.
+func (j *Job) Touch() error {
+	return j.conn.Touch(j.ID)
+}
+
+// Stats returns the beanstalk stats for this job, e.g. "reserves",
+// "releases" and "timeouts".
+func (j *Job) Stats() (map[string]string, error) {
+	return j.conn.StatsJob(j.ID)
+}
+
+// reserves returns how many times this job has been reserved, falling
+// back to 1 if the stat can't be read.
+func (j *Job) reserves() int {
+	stats, err := j.Stats()
+	if err != nil {
+		return 1
+	}
+
+	n, err := strconv.Atoi(stats["reserves"])
+	if err != nil {
+		return 1
+	}
+
+	return n
+}
@@ -0,0 +1,44 @@
+package workers
+
+import "time"
+
+// Metrics receives lifecycle callbacks from a Client as it reserves and
+// processes jobs, so operators can get throughput and failure visibility
+// without patching the library. Implementations must be safe for
+// concurrent use.
+type Metrics interface {
+	// OnJobReserved is called when a job is successfully reserved from
+	// tube, before its handler runs.
+	OnJobReserved(tube string)
+
+	// OnJobCompleted is called after a job's handler returns, with how
+	// long it ran and its outcome ("ok" or "panic").
+	OnJobCompleted(tube string, dur time.Duration, outcome string)
+
+	// OnReserveError is called when a non-timeout error is returned while
+	// reserving a job.
+	OnReserveError(err error)
+
+	// OnReconnect is called each time ConnectAndWork redials after a
+	// transient connection error.
+	OnReconnect()
+}
+
+// noopMetrics is the Metrics implementation used when Client.Metrics is
+// unset, so call sites never need to nil-check it.
+type noopMetrics struct{}
+
+func (noopMetrics) OnJobReserved(tube string) {}
+
+func (noopMetrics) OnJobCompleted(tube string, dur time.Duration, outcome string) {}
+
+func (noopMetrics) OnReserveError(err error) {}
+
+func (noopMetrics) OnReconnect() {}
+
+func (c *Client) metrics() Metrics {
+	if c.Metrics != nil {
+		return c.Metrics
+	}
+	return noopMetrics{}
+}
@@ -0,0 +1,70 @@
+package workers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeMetrics struct {
+	reserved   []string
+	completed  []string
+	errs       []error
+	reconnects int
+}
+
+func (m *fakeMetrics) OnJobReserved(tube string) {
+	m.reserved = append(m.reserved, tube)
+}
+
+func (m *fakeMetrics) OnJobCompleted(tube string, dur time.Duration, outcome string) {
+	m.completed = append(m.completed, tube+":"+outcome)
+}
+
+func (m *fakeMetrics) OnReserveError(err error) {
+	m.errs = append(m.errs, err)
+}
+
+func (m *fakeMetrics) OnReconnect() {
+	m.reconnects++
+}
+
+func TestClientMetricsDefaultsToNoop(t *testing.T) {
+	c := &Client{}
+
+	// None of these should panic with no Metrics configured.
+	c.metrics().OnJobReserved("tube")
+	c.metrics().OnJobCompleted("tube", time.Second, "ok")
+	c.metrics().OnReserveError(errors.New("boom"))
+	c.metrics().OnReconnect()
+}
+
+func TestClientMetricsUsesConfiguredImplementation(t *testing.T) {
+	fm := &fakeMetrics{}
+	c := &Client{Metrics: fm}
+
+	c.metrics().OnJobReserved("tube")
+	c.metrics().OnJobCompleted("tube", time.Second, "panic")
+	c.metrics().OnReserveError(errors.New("boom"))
+	c.metrics().OnReconnect()
+
+	if len(fm.reserved) != 1 || fm.reserved[0] != "tube" {
+		t.Fatalf("expected OnJobReserved to be forwarded, got %v", fm.reserved)
+	}
+	if len(fm.completed) != 1 || fm.completed[0] != "tube:panic" {
+		t.Fatalf("expected OnJobCompleted to be forwarded, got %v", fm.completed)
+	}
+	if len(fm.errs) != 1 {
+		t.Fatalf("expected OnReserveError to be forwarded, got %v", fm.errs)
+	}
+	if fm.reconnects != 1 {
+		t.Fatalf("expected OnReconnect to be forwarded, got %d", fm.reconnects)
+	}
+}
+
+func TestActiveWorkersDefaultsToZero(t *testing.T) {
+	c := &Client{}
+	if n := c.ActiveWorkers(); n != 0 {
+		t.Fatalf("expected 0 active workers, got %d", n)
+	}
+}
@@ -0,0 +1,168 @@
+package workers
+
+import "sync"
+
+// Handler responds to a reserved beanstalk Job.
+type Handler interface {
+	Work(j *Job)
+}
+
+// HandlerFunc is an adapter allowing ordinary functions to be used as
+// beanstalk job Handlers.
+type HandlerFunc func(j *Job)
+
+// Work calls f(j).
+func (f HandlerFunc) Work(j *Job) {
+	f(j)
+}
+
+// TubeOptions configures how a tube registered with WorkMux is scheduled
+// by Client.Reserve.
+type TubeOptions struct {
+	// MaxConcurrent caps the number of jobs from this tube that may be
+	// worked on at once, independent of Client.MaxControl. Zero means
+	// unbounded (subject only to the global MaxControl limit).
+	MaxConcurrent int
+
+	// Weight controls how often this tube is polled relative to others
+	// in the Reserve loop: a tube with Weight 2 is polled roughly twice
+	// as often as one with Weight 1. Zero is treated as 1.
+	Weight int
+}
+
+type muxEntry struct {
+	handler Handler
+	opts    TubeOptions
+	sem     chan struct{} // nil when opts.MaxConcurrent is unset
+}
+
+// WorkMux is a beanstalk job handler multiplexer. It matches each reserved
+// job against the tube it was reserved from and dispatches it to the
+// Handler registered for that tube.
+type WorkMux struct {
+	mu sync.RWMutex
+	m  map[string]*muxEntry
+}
+
+// NewWorkMux allocates and returns a new WorkMux.
+func NewWorkMux() *WorkMux {
+	return &WorkMux{m: make(map[string]*muxEntry)}
+}
+
+// Handle registers handler as the Handler for tube with default options
+// (unbounded per-tube concurrency, equal weight).
+func (mux *WorkMux) Handle(tube string, handler Handler) {
+	mux.HandleWithOptions(tube, handler, TubeOptions{})
+}
+
+// HandleWithOptions registers handler as the Handler for tube, applying
+// opts to how Client.Reserve schedules and bounds work on it.
+func (mux *WorkMux) HandleWithOptions(tube string, handler Handler, opts TubeOptions) {
+	if tube == "" {
+		panic("workers: invalid tube " + tube)
+	}
+	if handler == nil {
+		panic("workers: nil handler")
+	}
+	if opts.Weight <= 0 {
+		opts.Weight = 1
+	}
+
+	entry := &muxEntry{handler: handler, opts: opts}
+	if opts.MaxConcurrent > 0 {
+		entry.sem = make(chan struct{}, opts.MaxConcurrent)
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.m[tube] = entry
+}
+
+// Tubes returns the names of the tubes registered with the mux.
+func (mux *WorkMux) Tubes() []string {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	tubes := make([]string, 0, len(mux.m))
+	for tube := range mux.m {
+		tubes = append(tubes, tube)
+	}
+
+	return tubes
+}
+
+// weightedTubes returns the mux's tubes in an interleaved order that
+// favors higher-weight tubes, so that Client.Reserve polls them more
+// often than Go's randomized map iteration would.
+func (mux *WorkMux) weightedTubes() []string {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	type weighted struct {
+		name   string
+		weight int
+		credit int
+	}
+
+	entries := make([]*weighted, 0, len(mux.m))
+	maxWeight := 1
+	total := 0
+	for name, entry := range mux.m {
+		w := entry.opts.Weight
+		if w <= 0 {
+			w = 1
+		}
+		if w > maxWeight {
+			maxWeight = w
+		}
+		total += w
+		entries = append(entries, &weighted{name: name, weight: w})
+	}
+
+	order := make([]string, 0, total)
+	for len(order) < total {
+		for _, e := range entries {
+			e.credit += e.weight
+			if e.credit >= maxWeight {
+				e.credit -= maxWeight
+				order = append(order, e.name)
+			}
+		}
+	}
+
+	return order
+}
+
+// acquire reserves a per-tube concurrency slot for tube, returning a
+// release function and true if a slot was obtained. Tubes without a
+// MaxConcurrent limit always succeed immediately.
+func (mux *WorkMux) acquire(tube string) (release func(), ok bool) {
+	mux.mu.RLock()
+	entry, found := mux.m[tube]
+	mux.mu.RUnlock()
+
+	if !found || entry.sem == nil {
+		return func() {}, true
+	}
+
+	select {
+	case entry.sem <- struct{}{}:
+		return func() { <-entry.sem }, true
+	default:
+		return nil, false
+	}
+}
+
+// Work dispatches j to the handler registered for its tube, if any.
+func (mux *WorkMux) Work(j *Job) {
+	mux.mu.RLock()
+	entry, ok := mux.m[j.Tube]
+	mux.mu.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	entry.handler.Work(j)
+}
@@ -0,0 +1,66 @@
+package workers
+
+import "testing"
+
+func TestWeightedTubesInterleavesByWeight(t *testing.T) {
+	mux := NewWorkMux()
+	mux.HandleWithOptions("hi", HandlerFunc(func(j *Job) {}), TubeOptions{Weight: 3})
+	mux.HandleWithOptions("lo", HandlerFunc(func(j *Job) {}), TubeOptions{Weight: 1})
+
+	order := mux.weightedTubes()
+	if len(order) != 4 {
+		t.Fatalf("expected 4 entries for weights summing to 4, got %d: %v", len(order), order)
+	}
+
+	counts := map[string]int{}
+	for _, name := range order {
+		counts[name]++
+	}
+	if counts["hi"] != 3 || counts["lo"] != 1 {
+		t.Fatalf("expected hi=3 lo=1, got %v", counts)
+	}
+}
+
+func TestWeightedTubesDefaultsUnweighedToOne(t *testing.T) {
+	mux := NewWorkMux()
+	mux.Handle("a", HandlerFunc(func(j *Job) {}))
+	mux.Handle("b", HandlerFunc(func(j *Job) {}))
+
+	order := mux.weightedTubes()
+	if len(order) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %v", len(order), order)
+	}
+}
+
+func TestAcquireRespectsMaxConcurrent(t *testing.T) {
+	mux := NewWorkMux()
+	mux.HandleWithOptions("tube", HandlerFunc(func(j *Job) {}), TubeOptions{MaxConcurrent: 1})
+
+	release, ok := mux.acquire("tube")
+	if !ok {
+		t.Fatal("expected first acquire to succeed")
+	}
+
+	if _, ok := mux.acquire("tube"); ok {
+		t.Fatal("expected second acquire to fail while the slot is held")
+	}
+
+	release()
+
+	release2, ok := mux.acquire("tube")
+	if !ok {
+		t.Fatal("expected acquire to succeed after release")
+	}
+	release2()
+}
+
+func TestAcquireUnboundedTubeAlwaysSucceeds(t *testing.T) {
+	mux := NewWorkMux()
+	mux.Handle("tube", HandlerFunc(func(j *Job) {}))
+
+	for i := 0; i < 3; i++ {
+		if _, ok := mux.acquire("tube"); !ok {
+			t.Fatal("expected an unbounded tube to always acquire")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package prometheus implements workers.Metrics on top of Prometheus
+// collectors, so a Client's throughput and failure rates can be scraped
+// without the caller writing any glue code.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements workers.Metrics, exporting per-tube job counters and
+// a reserve-error counter and active-workers gauge.
+type Metrics struct {
+	jobsProcessed *prometheus.CounterVec
+	jobDuration   *prometheus.HistogramVec
+	reserveErrors prometheus.Counter
+	activeWorkers prometheus.Gauge
+}
+
+// New creates a Metrics and registers its collectors with reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		jobsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jobs_processed_total",
+			Help: "Total number of jobs processed, labeled by tube and outcome.",
+		}, []string{"tube", "outcome"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "job_duration_seconds",
+			Help: "Time spent running a job handler, labeled by tube.",
+		}, []string{"tube"}),
+		reserveErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reserve_errors_total",
+			Help: "Total number of non-timeout errors returned while reserving jobs.",
+		}),
+		activeWorkers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "active_workers",
+			Help: "Number of job handlers currently running.",
+		}),
+	}
+
+	reg.MustRegister(m.jobsProcessed, m.jobDuration, m.reserveErrors, m.activeWorkers)
+
+	return m
+}
+
+// OnJobReserved implements workers.Metrics.
+func (m *Metrics) OnJobReserved(tube string) {
+	m.activeWorkers.Inc()
+}
+
+// OnJobCompleted implements workers.Metrics.
+func (m *Metrics) OnJobCompleted(tube string, dur time.Duration, outcome string) {
+	m.activeWorkers.Dec()
+	m.jobsProcessed.WithLabelValues(tube, outcome).Inc()
+	m.jobDuration.WithLabelValues(tube).Observe(dur.Seconds())
+}
+
+// OnReserveError implements workers.Metrics.
+func (m *Metrics) OnReserveError(err error) {
+	m.reserveErrors.Inc()
+}
+
+// OnReconnect implements workers.Metrics.
+func (m *Metrics) OnReconnect() {}
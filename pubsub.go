@@ -0,0 +1,122 @@
+package workers
+
+import "sync"
+
+// PubSub is a lightweight intra-process message bus layered on a
+// WorkMux: each job reserved for a topic (a tube) is broadcast to every
+// subscriber registered for that topic before the job is removed from
+// beanstalk.
+type PubSub struct {
+	mux *WorkMux
+
+	mu   sync.RWMutex
+	subs map[string][]*subscriber
+}
+
+type subscriber struct {
+	fn func([]byte) error
+}
+
+// NewPubSub creates a PubSub whose topics are reserved via mux.
+func NewPubSub(mux *WorkMux) *PubSub {
+	return &PubSub{mux: mux, subs: make(map[string][]*subscriber)}
+}
+
+// Subscribe registers fn to receive every message published to topic,
+// returning a function that removes the subscription. The job is deleted
+// once every subscriber for its topic has run, or buried if any of them
+// panics.
+func (p *PubSub) Subscribe(topic string, fn func([]byte)) (unsubscribe func()) {
+	return p.subscribe(topic, func(body []byte) error {
+		fn(body)
+		return nil
+	})
+}
+
+// SubscribeE is the error-returning variant of Subscribe: if fn returns an
+// error, the job is buried instead of deleted once all of the topic's
+// subscribers have run.
+func (p *PubSub) SubscribeE(topic string, fn func([]byte) error) (unsubscribe func()) {
+	return p.subscribe(topic, fn)
+}
+
+func (p *PubSub) subscribe(topic string, fn func([]byte) error) func() {
+	sub := &subscriber{fn: fn}
+
+	p.mu.Lock()
+	wasEmpty := len(p.subs[topic]) == 0
+	p.subs[topic] = append(copySubs(p.subs[topic]), sub)
+	p.mu.Unlock()
+
+	if wasEmpty {
+		p.mux.Handle(topic, HandlerFunc(func(j *Job) {
+			p.publish(topic, j)
+		}))
+	}
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		existing := p.subs[topic]
+		next := make([]*subscriber, 0, len(existing))
+		for _, s := range existing {
+			if s != sub {
+				next = append(next, s)
+			}
+		}
+		p.subs[topic] = next
+	}
+}
+
+// publish broadcasts j's body to every subscriber of topic concurrently,
+// isolating each subscriber's panics from the others, then deletes j, or
+// buries it if any subscriber returned an error or panicked.
+func (p *PubSub) publish(topic string, j *Job) {
+	p.mu.RLock()
+	subs := p.subs[topic]
+	p.mu.RUnlock()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *subscriber) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}()
+
+			if err := sub.fn(j.Body); err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(sub)
+	}
+
+	wg.Wait()
+
+	if failed {
+		j.Bury(0)
+		return
+	}
+
+	j.Delete()
+}
+
+// copySubs returns a copy of subs, for the copy-on-write update in
+// subscribe.
+func copySubs(subs []*subscriber) []*subscriber {
+	next := make([]*subscriber, len(subs))
+	copy(next, subs)
+	return next
+}
@@ -0,0 +1,20 @@
+package workers
+
+import "testing"
+
+func TestPubSubUnsubscribeRemovesSubscriber(t *testing.T) {
+	ps := NewPubSub(NewWorkMux())
+
+	unsubscribeA := ps.Subscribe("topic", func([]byte) {})
+	ps.Subscribe("topic", func([]byte) {})
+
+	if n := len(ps.subs["topic"]); n != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", n)
+	}
+
+	unsubscribeA()
+
+	if n := len(ps.subs["topic"]); n != 1 {
+		t.Fatalf("expected 1 subscriber after unsubscribe, got %d", n)
+	}
+}